@@ -19,9 +19,10 @@ package kafka
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -30,8 +31,10 @@ import (
 	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
 	"github.com/knative/eventing-sources/pkg/kncloudevents"
 	"github.com/knative/pkg/logging"
+	"go.opencensus.io/trace"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -42,6 +45,25 @@ type AdapterSASL struct {
 	Enable   bool
 	User     string
 	Password string
+	// Mechanism selects the SASL authentication mechanism: "PLAIN" (the
+	// default), "SCRAM-SHA-256", "SCRAM-SHA-512", or "OAUTHBEARER". Many
+	// managed Kafka providers (Confluent Cloud, MSK, Aiven) reject PLAIN
+	// and require one of the others.
+	Mechanism string
+	// OAuth configures the OAUTHBEARER mechanism and is only consulted
+	// when Mechanism is "OAUTHBEARER".
+	OAuth AdapterOAuth
+}
+
+// AdapterOAuth configures how the adapter obtains a bearer token for the
+// OAUTHBEARER SASL mechanism. Either StaticToken or TokenEndpoint (with
+// ClientID/ClientSecret) must be set.
+type AdapterOAuth struct {
+	StaticToken   string
+	TokenEndpoint string
+	ClientID      string
+	ClientSecret  string
+	Scopes        []string
 }
 
 type AdapterTLS struct {
@@ -62,9 +84,100 @@ type Adapter struct {
 	ConsumerGroup    string
 	Net              AdapterNet
 	SinkURI          string
-	client           client.Client
+	// InitialOffset controls where a consumer group with no committed
+	// offsets starts reading from: "earliest" (the default) or "latest".
+	InitialOffset string
+	// ResetOffsets, when true, deletes any committed offsets for
+	// ConsumerGroup before joining, forcing the group to (re)start from
+	// InitialOffset. Useful for high-throughput topics where replaying
+	// the full backlog on every deploy is undesirable.
+	ResetOffsets bool
+	// EventsPerSecond caps the steady-state rate at which events are
+	// dispatched to SinkURI, using a token-bucket limiter. Zero disables
+	// rate limiting.
+	EventsPerSecond float64
+	// Burst is the token-bucket burst size. Defaults to 1 when
+	// EventsPerSecond is set and Burst is zero.
+	Burst int
+	// Concurrency bounds how many dispatch workers run in parallel.
+	// Messages from the same partition always land on the same worker,
+	// so in-partition ordering is preserved while different partitions
+	// can be dispatched concurrently. Defaults to 1 (fully serial).
+	Concurrency int
+	// MaxRetries bounds the number of retries for a failed dispatch
+	// before the message is given up on without being marked. Defaults
+	// to 0 (no retries).
+	MaxRetries int
+	// InitialBackoff and MaxBackoff bound the exponential backoff
+	// applied between retries. InitialBackoff defaults to 100ms,
+	// MaxBackoff to 30s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// KeyType controls how msg.Key is decoded into the "key" CloudEvent
+	// extension: "string" (the default), "int", "float", or "byte-array".
+	KeyType string
+	// HeaderExtensionPrefix is prepended to each Kafka record header name
+	// when copying it onto the CloudEvent as an extension attribute.
+	// Defaults to "kafkaheader".
+	HeaderExtensionPrefix string
+	// Decoder selects the PayloadDecoder used to turn a record's raw
+	// value into CloudEvent data: "json" (the default), "avro-confluent",
+	// "protobuf", or "raw".
+	Decoder string
+	// SchemaRegistry configures schema lookups for the avro-confluent
+	// decoder.
+	SchemaRegistry AdapterSchemaRegistry
+	// ProtobufDescriptorSet is the serialized bytes of a
+	// google.protobuf.FileDescriptorSet, used by the protobuf decoder.
+	ProtobufDescriptorSet []byte
+	// ProtobufMessageType is the fully-qualified name of the message
+	// type to decode payloads as, used by the protobuf decoder.
+	ProtobufMessageType string
+
+	// MetadataRefreshInterval controls how often Start refreshes cluster
+	// metadata and logs any partition/leader changes it observes.
+	// Defaults to 10 minutes.
+	MetadataRefreshInterval time.Duration
+	// HealthAddr is the address the /healthz and /readyz endpoints are
+	// served on. Defaults to ":8080".
+	HealthAddr string
+	// MetricsAddr is the address the Prometheus /metrics endpoint is
+	// served on. Defaults to ":9090".
+	MetricsAddr string
+
+	client         client.Client
+	limiter        *rate.Limiter
+	workersOnce    sync.Once
+	workerQueue    []chan dispatchJob
+	payloadDecoder PayloadDecoder
+	offsetsReset   bool
+	health         healthState
+	// runCtx is the context passed to Start, canceled on shutdown. It is
+	// set once before the consumer group is joined, so dispatch workers
+	// can honor a graceful shutdown instead of blocking it on an
+	// in-flight rate-limit wait or retry backoff.
+	runCtx context.Context
 }
 
+// context returns the context threaded from Start, or context.TODO() if
+// the adapter hasn't been started yet (e.g. exercised directly in tests).
+func (a *Adapter) context() context.Context {
+	if a.runCtx != nil {
+		return a.runCtx
+	}
+	return context.TODO()
+}
+
+const (
+	// OffsetEarliest starts a new consumer group at the oldest available
+	// offset, replaying the full retained backlog of each topic.
+	OffsetEarliest = "earliest"
+	// OffsetLatest starts a new consumer group at the newest offset,
+	// skipping any backlog and only consuming events produced from here
+	// on.
+	OffsetLatest = "latest"
+)
+
 // --------------------------------------------------------------------
 
 // ConsumerGroupHandler functions to define message consume and related logic.
@@ -75,125 +188,156 @@ func (a *Adapter) Setup(_ sarama.ConsumerGroupSession) error {
 			return err
 		}
 	}
+	if err := a.initDecoder(); err != nil {
+		return err
+	}
+	a.startDispatchWorkers()
+	// Setup runs once the session has joined the consumer group, so this
+	// is the point at which /readyz starts reporting ready.
+	a.setHealthy(true)
 	return nil
 }
 func (a *Adapter) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 func (a *Adapter) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 
-	logger := logging.FromContext(context.TODO())
+	ctx := a.context()
+	logger := logging.FromContext(ctx)
 
+	var wg sync.WaitGroup
 	for msg := range claim.Messages() {
 		logger.Debug("Received: ", zap.String("topic:", msg.Topic),
 			zap.Int32("partition:", msg.Partition),
 			zap.Int64("offset:", msg.Offset))
+		a.recordPoll()
+		recordEventConsumed(ctx, msg.Topic, msg.Partition)
 
-		// send and mark message if post was successful
-		if err := a.postMessage(context.TODO(), msg); err == nil {
-			sess.MarkMessage(msg, "")
-			logger.Debug("Successfully sent event to sink")
-		} else {
-			logger.Error("Sending event to sink failed: ", zap.Error(err))
-		}
+		wg.Add(1)
+		a.dispatchQueueFor(msg.Partition) <- dispatchJob{ctx: ctx, sess: sess, claim: claim, msg: msg, done: wg.Done}
 	}
+	// Wait for every message handed off during this claim to be
+	// dispatched (and, if the sink accepted it, marked) before returning,
+	// so a rebalance doesn't
+	// silently drop in-flight work.
+	wg.Wait()
 	return nil
 }
 
 // --------------------------------------------------------------------
 
+// Start runs the adapter until stopCh is closed or ctx is canceled. Rather
+// than panicking, transient failures (a broker that's down, a metadata
+// refresh error) are retried with exponential backoff so the adapter stays
+// up; /healthz and /readyz reflect the current state for Kubernetes
+// liveness/readiness probes.
 func (a *Adapter) Start(ctx context.Context, stopCh <-chan struct{}) error {
 	logger := logging.FromContext(ctx)
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	a.runCtx = ctx
+	go func() {
+		select {
+		case <-stopCh:
+			logger.Info("Shutting down...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	a.startHealthServer(logger)
+	a.startMetricsServer(logger)
+
+	return a.runSupervised(ctx, logger)
+}
+
+func (a *Adapter) newKafkaConfig() (*sarama.Config, error) {
 	kafkaConfig := sarama.NewConfig()
-	kafkaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	kafkaConfig.Consumer.Offsets.Initial = initialOffset(a.InitialOffset)
 	kafkaConfig.Version = sarama.V2_0_0_0
 	kafkaConfig.Consumer.Return.Errors = true
-	kafkaConfig.Net.SASL.Enable = a.Net.SASL.Enable
-	kafkaConfig.Net.SASL.User = a.Net.SASL.User
-	kafkaConfig.Net.SASL.Password = a.Net.SASL.Password
+	if err := a.configureSASL(kafkaConfig); err != nil {
+		return nil, err
+	}
 	kafkaConfig.Net.TLS.Enable = a.Net.TLS.Enable
 
 	if a.Net.TLS.Enable && a.Net.TLS.Cert != "" {
 		tlsConfig, err := newTLSConfig(a.Net.TLS.Cert, a.Net.TLS.Key, a.Net.TLS.CACert)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		kafkaConfig.Net.TLS.Config = tlsConfig
 	}
+	return kafkaConfig, nil
+}
 
-	// Start with a client
-	client, err := sarama.NewClient(strings.Split(a.BootstrapServers, ","), kafkaConfig)
-	if err != nil {
-		panic(err)
+// initialOffset maps the adapter's InitialOffset setting to the
+// corresponding sarama offset constant, defaulting to the oldest offset.
+func initialOffset(offset string) int64 {
+	if offset == OffsetLatest {
+		return sarama.OffsetNewest
 	}
-	defer func() { _ = client.Close() }()
+	return sarama.OffsetOldest
+}
 
-	// init consumer group
-	group, err := sarama.NewConsumerGroupFromClient(a.ConsumerGroup, client)
+// resetConsumerGroupOffsets deletes any offsets committed for
+// a.ConsumerGroup across all partitions of a.Topics, so that the group
+// restarts from InitialOffset instead of resuming where it left off.
+func (a *Adapter) resetConsumerGroupOffsets(client sarama.Client, kafkaConfig *sarama.Config) error {
+	admin, err := sarama.NewClusterAdminFromClient(client)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer func() { _ = group.Close() }()
+	defer func() { _ = admin.Close() }()
 
-	// Track errors
-	go func() {
-		for err := range group.Errors() {
-			logger.Error("ERROR", err)
+	for _, topic := range strings.Split(a.Topics, ",") {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return err
 		}
-	}()
-
-	// Handle session
-	go func() {
-		for {
-			if err := group.Consume(ctx, strings.Split(a.Topics, ","), a); err != nil {
-				panic(err)
+		for _, partition := range partitions {
+			if err := admin.DeleteConsumerGroupOffset(a.ConsumerGroup, topic, partition); err != nil {
+				return fmt.Errorf("kafka: deleting committed offset for %s/%d: %w", topic, partition, err)
 			}
 		}
-	}()
-
-	for {
-		select {
-		case <-stopCh:
-			logger.Info("Shutting down...")
-			return nil
-		}
 	}
+	return nil
 }
 
 func (a *Adapter) postMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	ctx, span := trace.StartSpan(ctx, "kafka.postMessage")
+	defer span.End()
 
-	extensions := map[string]interface{}{
-		"key": string(msg.Key),
+	var event cloudevents.Event
+	if embedded, ok := a.parseEmbeddedCloudEvent(ctx, msg); ok {
+		event = *embedded
+	} else {
+		extensions := a.headerExtensions(msg.Headers)
+		extensions["key"] = a.encodeKey(msg.Key)
+		data, contentType := a.decodePayload(ctx, msg.Value)
+		event = cloudevents.Event{
+			Context: cloudevents.EventContextV02{
+				SpecVersion: cloudevents.CloudEventsVersionV02,
+				Type:        eventType,
+				ID:          "partition:" + strconv.Itoa(int(msg.Partition)) + "/offset:" + strconv.FormatInt(msg.Offset, 10),
+				Time:        &types.Timestamp{Time: msg.Timestamp},
+				Source:      *types.ParseURLRef(msg.Topic),
+				ContentType: &contentType,
+				Extensions:  extensions,
+			}.AsV02(),
+			Data: data,
+		}
 	}
-	event := cloudevents.Event{
-		Context: cloudevents.EventContextV02{
-			SpecVersion: cloudevents.CloudEventsVersionV02,
-			Type:        eventType,
-			ID:          "partition:" + strconv.Itoa(int(msg.Partition)) + "/offset:" + strconv.FormatInt(msg.Offset, 10),
-			Time:        &types.Timestamp{Time: msg.Timestamp},
-			Source:      *types.ParseURLRef(msg.Topic),
-			ContentType: cloudevents.StringOfApplicationJSON(),
-			Extensions:  extensions,
-		}.AsV02(),
-		Data: a.jsonEncode(ctx, msg.Value),
+
+	// Set unconditionally, for both synthesized and embedded events, so
+	// a downstream sink can continue this span's trace either way.
+	if err := event.Context.SetExtension("traceparent", traceParentHeader(span.SpanContext())); err != nil {
+		logging.FromContext(ctx).Info("Setting traceparent extension failed: ", zap.Error(err))
 	}
 
 	_, err := a.client.Send(ctx, event)
 	return err
 }
 
-func (a *Adapter) jsonEncode(ctx context.Context, value []byte) interface{} {
-	var payload map[string]interface{}
-
-	logger := logging.FromContext(ctx)
-
-	if err := json.Unmarshal(value, &payload); err != nil {
-		logger.Info("Error unmarshalling JSON: ", zap.Error(err))
-		return value
-	} else {
-		return payload
-	}
-}
-
 func newTLSConfig(clientCert, clientKey, caCert string) (*tls.Config, error) {
 	cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
 	if err != nil {
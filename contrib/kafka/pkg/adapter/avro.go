@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"golang.org/x/net/context"
+)
+
+// schemaRegistryRequestTimeout bounds how long a single schema lookup can
+// take. schemaByID is called synchronously from Decode, which runs inside
+// dispatchWithRetry's single attempt, so a hung registry must not be
+// allowed to block that dispatch worker (and the partition routed to it)
+// indefinitely, the same reasoning behind sasl.go's oauthTokenHTTPClient.
+const schemaRegistryRequestTimeout = 10 * time.Second
+
+// AdapterSchemaRegistry configures how the avro-confluent PayloadDecoder
+// looks up writer schemas.
+type AdapterSchemaRegistry struct {
+	URL      string
+	Username string
+	Password string
+	TLS      AdapterTLS
+}
+
+// avroConfluentDecoder decodes payloads written in the Confluent wire
+// format: a leading magic byte (0), a 4-byte big-endian schema ID, and the
+// Avro-binary-encoded value. Decoded schemas are cached in-process by
+// schema ID, since registry lookups are comparatively expensive.
+type avroConfluentDecoder struct {
+	registry *schemaRegistryClient
+
+	mu     sync.RWMutex
+	codecs map[int32]*goavro.Codec
+}
+
+func newAvroConfluentDecoder(cfg AdapterSchemaRegistry) (*avroConfluentDecoder, error) {
+	registry, err := newSchemaRegistryClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &avroConfluentDecoder{
+		registry: registry,
+		codecs:   make(map[int32]*goavro.Codec),
+	}, nil
+}
+
+func (d *avroConfluentDecoder) Decode(ctx context.Context, value []byte) (interface{}, string, error) {
+	if len(value) < 5 || value[0] != 0 {
+		return nil, "", fmt.Errorf("kafka: payload is missing the Confluent avro magic byte")
+	}
+	schemaID := int32(binary.BigEndian.Uint32(value[1:5]))
+
+	codec, err := d.codecFor(ctx, schemaID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	native, _, err := codec.NativeFromBinary(value[5:])
+	if err != nil {
+		return nil, "", fmt.Errorf("kafka: decoding avro payload for schema %d: %w", schemaID, err)
+	}
+	return native, "application/avro+json", nil
+}
+
+func (d *avroConfluentDecoder) codecFor(ctx context.Context, schemaID int32) (*goavro.Codec, error) {
+	d.mu.RLock()
+	codec, ok := d.codecs[schemaID]
+	d.mu.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	schema, err := d.registry.schemaByID(ctx, schemaID)
+	if err != nil {
+		return nil, err
+	}
+	codec, err = goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: parsing avro schema %d: %w", schemaID, err)
+	}
+
+	d.mu.Lock()
+	d.codecs[schemaID] = codec
+	d.mu.Unlock()
+	return codec, nil
+}
+
+// schemaRegistryClient fetches writer schemas by ID from a Confluent-style
+// Schema Registry, with optional basic-auth and TLS.
+type schemaRegistryClient struct {
+	cfg    AdapterSchemaRegistry
+	client *http.Client
+}
+
+func newSchemaRegistryClient(cfg AdapterSchemaRegistry) (*schemaRegistryClient, error) {
+	httpClient := &http.Client{Timeout: schemaRegistryRequestTimeout}
+	if cfg.TLS.Enable {
+		tlsConfig, err := newTLSConfig(cfg.TLS.Cert, cfg.TLS.Key, cfg.TLS.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: configuring schema registry TLS: %w", err)
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	return &schemaRegistryClient{cfg: cfg, client: httpClient}, nil
+}
+
+func (c *schemaRegistryClient) schemaByID(ctx context.Context, id int32) (string, error) {
+	url := fmt.Sprintf("%s/schemas/ids/%d", strings.TrimRight(c.cfg.URL, "/"), id)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("kafka: fetching schema %d from registry: %w", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("kafka: schema registry returned status %d for schema %d", resp.StatusCode, id)
+	}
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("kafka: decoding schema registry response for schema %d: %w", id, err)
+	}
+	return body.Schema, nil
+}
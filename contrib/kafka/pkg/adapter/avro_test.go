@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"golang.org/x/net/context"
+)
+
+const testAvroSchema = `{"type":"record","name":"Test","fields":[{"name":"greeting","type":"string"}]}`
+
+// confluentEncode Avro-encodes native under schema and wraps it in the
+// Confluent wire format (magic byte + 4-byte schema ID), mirroring what a
+// real producer using the Confluent Avro serializer would write.
+func confluentEncode(t *testing.T, schemaID int32, schema string, native map[string]interface{}) []byte {
+	t.Helper()
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatalf("goavro.NewCodec() failed: %v", err)
+	}
+	binaryValue, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatalf("BinaryFromNative() failed: %v", err)
+	}
+
+	buf := make([]byte, 5+len(binaryValue))
+	binary.BigEndian.PutUint32(buf[1:5], uint32(schemaID))
+	copy(buf[5:], binaryValue)
+	return buf
+}
+
+// newFakeSchemaRegistry serves schema for any lookup of schemaID, imitating
+// the Confluent Schema Registry's GET /schemas/ids/{id} endpoint.
+func newFakeSchemaRegistry(t *testing.T, schemaID int32, schema string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/schemas/ids/%d", schemaID) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"schema": %q}`, schema)
+	}))
+}
+
+func TestAvroConfluentDecoder_Decode(t *testing.T) {
+	server := newFakeSchemaRegistry(t, 7, testAvroSchema)
+	defer server.Close()
+
+	decoder, err := newAvroConfluentDecoder(AdapterSchemaRegistry{URL: server.URL})
+	if err != nil {
+		t.Fatalf("newAvroConfluentDecoder() failed: %v", err)
+	}
+
+	payload := confluentEncode(t, 7, testAvroSchema, map[string]interface{}{"greeting": "hello"})
+	data, contentType, err := decoder.Decode(context.TODO(), payload)
+	if err != nil {
+		t.Fatalf("Decode() returned error: %v", err)
+	}
+	if contentType != "application/avro+json" {
+		t.Errorf("Decode() contentType = %q, want %q", contentType, "application/avro+json")
+	}
+	native, ok := data.(map[string]interface{})
+	if !ok || native["greeting"] != "hello" {
+		t.Errorf("Decode() data = %#v, want map with greeting=hello", data)
+	}
+
+	// The codec for schema 7 is now cached, so a second decode must not
+	// need to reach the (now shut down) registry.
+	server.Close()
+	if _, _, err := decoder.Decode(context.TODO(), payload); err != nil {
+		t.Errorf("Decode() with a cached codec returned error after registry shutdown: %v", err)
+	}
+}
+
+func TestAvroConfluentDecoder_Decode_MalformedPayload(t *testing.T) {
+	decoder := &avroConfluentDecoder{codecs: make(map[int32]*goavro.Codec)}
+
+	if _, _, err := decoder.Decode(context.TODO(), []byte{1, 2, 3}); err == nil {
+		t.Error("Decode() with a too-short payload returned nil error, want an error")
+	}
+	if _, _, err := decoder.Decode(context.TODO(), []byte{1, 0, 0, 0, 7}); err == nil {
+		t.Error("Decode() with a non-zero magic byte returned nil error, want an error")
+	}
+}
+
+func TestNewSchemaRegistryClient_TLSError(t *testing.T) {
+	_, err := newSchemaRegistryClient(AdapterSchemaRegistry{
+		TLS: AdapterTLS{Enable: true, Cert: "not-a-valid-cert", Key: "not-a-valid-key"},
+	})
+	if err == nil {
+		t.Error("newSchemaRegistryClient() with an invalid TLS cert returned nil error, want it surfaced rather than silently falling back to a non-TLS client")
+	}
+}
+
+func TestNewSchemaRegistryClient_HasTimeout(t *testing.T) {
+	client, err := newSchemaRegistryClient(AdapterSchemaRegistry{})
+	if err != nil {
+		t.Fatalf("newSchemaRegistryClient() returned error: %v", err)
+	}
+	if client.client.Timeout != schemaRegistryRequestTimeout {
+		t.Errorf("newSchemaRegistryClient() client.Timeout = %v, want %v", client.client.Timeout, schemaRegistryRequestTimeout)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"encoding/json"
+
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DecoderJSON decodes the payload as JSON (the default).
+	DecoderJSON = "json"
+	// DecoderAvroConfluent decodes the payload as Avro, using the 5-byte
+	// Confluent wire format (a magic byte followed by a 4-byte schema ID)
+	// to look the writer schema up in a Schema Registry.
+	DecoderAvroConfluent = "avro-confluent"
+	// DecoderProtobuf decodes the payload as a Protocol Buffers message,
+	// resolved against a registered descriptor set.
+	DecoderProtobuf = "protobuf"
+	// DecoderRaw passes the payload through unchanged.
+	DecoderRaw = "raw"
+)
+
+// PayloadDecoder turns a raw Kafka record value into the data and content
+// type of the CloudEvent that will carry it.
+type PayloadDecoder interface {
+	Decode(ctx context.Context, value []byte) (data interface{}, contentType string, err error)
+}
+
+// jsonDecoder is the default PayloadDecoder, preserving the adapter's
+// original behavior of attempting a JSON decode and falling back to the
+// raw bytes if the payload isn't valid JSON.
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(ctx context.Context, value []byte) (interface{}, string, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(value, &payload); err != nil {
+		logging.FromContext(ctx).Info("Error unmarshalling JSON: ", zap.Error(err))
+		return value, "application/octet-stream", nil
+	}
+	return payload, "application/json", nil
+}
+
+// rawDecoder passes the payload through unchanged.
+type rawDecoder struct{}
+
+func (rawDecoder) Decode(_ context.Context, value []byte) (interface{}, string, error) {
+	return value, "application/octet-stream", nil
+}
+
+// initDecoder builds and caches the PayloadDecoder selected by a.Decoder.
+// It is called from Setup at the start of every session; once a decoder is
+// set, later calls leave it unchanged.
+func (a *Adapter) initDecoder() error {
+	if a.payloadDecoder != nil {
+		return nil
+	}
+
+	switch a.Decoder {
+	case DecoderAvroConfluent:
+		decoder, err := newAvroConfluentDecoder(a.SchemaRegistry)
+		if err != nil {
+			return err
+		}
+		a.payloadDecoder = decoder
+	case DecoderProtobuf:
+		decoder, err := newProtobufDecoder(a.ProtobufDescriptorSet, a.ProtobufMessageType)
+		if err != nil {
+			return err
+		}
+		a.payloadDecoder = decoder
+	case DecoderRaw:
+		a.payloadDecoder = rawDecoder{}
+	default:
+		a.payloadDecoder = jsonDecoder{}
+	}
+	return nil
+}
+
+// decodePayload runs the configured PayloadDecoder over value, falling back
+// to the raw bytes (and logging) if decoding fails.
+func (a *Adapter) decodePayload(ctx context.Context, value []byte) (interface{}, string) {
+	data, contentType, err := a.payloadDecoder.Decode(ctx, value)
+	if err != nil {
+		logging.FromContext(ctx).Info("Error decoding payload: ", zap.Error(err))
+		return value, "application/octet-stream"
+	}
+	return data, contentType
+}
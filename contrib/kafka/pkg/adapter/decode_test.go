@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestJSONDecoder_Decode(t *testing.T) {
+	var d jsonDecoder
+
+	data, contentType, err := d.Decode(context.TODO(), []byte(`{"hello":"world"}`))
+	if err != nil {
+		t.Fatalf("Decode() valid JSON returned error: %v", err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Decode() valid JSON contentType = %q, want %q", contentType, "application/json")
+	}
+	payload, ok := data.(map[string]interface{})
+	if !ok || payload["hello"] != "world" {
+		t.Errorf("Decode() valid JSON data = %#v, want map with hello=world", data)
+	}
+}
+
+func TestJSONDecoder_Decode_FallsBackOnInvalidJSON(t *testing.T) {
+	var d jsonDecoder
+	raw := []byte("not json")
+
+	data, contentType, err := d.Decode(context.TODO(), raw)
+	if err != nil {
+		t.Fatalf("Decode() invalid JSON returned error %v, want fallback to raw bytes instead", err)
+	}
+	if contentType != "application/octet-stream" {
+		t.Errorf("Decode() invalid JSON contentType = %q, want %q", contentType, "application/octet-stream")
+	}
+	got, ok := data.([]byte)
+	if !ok || !bytes.Equal(got, raw) {
+		t.Errorf("Decode() invalid JSON data = %#v, want raw bytes %q", data, raw)
+	}
+}
+
+func TestRawDecoder_Decode(t *testing.T) {
+	var d rawDecoder
+	raw := []byte("anything at all")
+
+	data, contentType, err := d.Decode(context.TODO(), raw)
+	if err != nil {
+		t.Fatalf("Decode() returned error: %v", err)
+	}
+	if contentType != "application/octet-stream" {
+		t.Errorf("Decode() contentType = %q, want %q", contentType, "application/octet-stream")
+	}
+	got, ok := data.([]byte)
+	if !ok || !bytes.Equal(got, raw) {
+		t.Errorf("Decode() data = %#v, want raw bytes %q unchanged", data, raw)
+	}
+}
+
+func TestInitDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		decoder string
+		want    interface{}
+	}{
+		{name: "default is json", decoder: "", want: jsonDecoder{}},
+		{name: "explicit json", decoder: DecoderJSON, want: jsonDecoder{}},
+		{name: "raw", decoder: DecoderRaw, want: rawDecoder{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Adapter{Decoder: tt.decoder}
+			if err := a.initDecoder(); err != nil {
+				t.Fatalf("initDecoder() returned error: %v", err)
+			}
+			if a.payloadDecoder != tt.want {
+				t.Errorf("initDecoder() payloadDecoder = %#v, want %#v", a.payloadDecoder, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitDecoder_IsIdempotent(t *testing.T) {
+	a := &Adapter{Decoder: DecoderRaw}
+	if err := a.initDecoder(); err != nil {
+		t.Fatalf("initDecoder() returned error: %v", err)
+	}
+	a.payloadDecoder = jsonDecoder{}
+
+	if err := a.initDecoder(); err != nil {
+		t.Fatalf("initDecoder() returned error: %v", err)
+	}
+	if _, ok := a.payloadDecoder.(jsonDecoder); !ok {
+		t.Errorf("initDecoder() replaced an already-set payloadDecoder, want it left untouched once set")
+	}
+}
+
+func TestDecodePayload_FallsBackOnDecoderError(t *testing.T) {
+	a := &Adapter{payloadDecoder: erroringDecoder{}}
+	raw := []byte("whatever")
+
+	data, contentType := a.decodePayload(context.TODO(), raw)
+	if contentType != "application/octet-stream" {
+		t.Errorf("decodePayload() contentType = %q, want %q", contentType, "application/octet-stream")
+	}
+	got, ok := data.([]byte)
+	if !ok || !bytes.Equal(got, raw) {
+		t.Errorf("decodePayload() data = %#v, want raw bytes %q on decoder error", data, raw)
+	}
+}
+
+type erroringDecoder struct{}
+
+func (erroringDecoder) Decode(_ context.Context, _ []byte) (interface{}, string, error) {
+	return nil, "", errDecodeFailed
+}
+
+var errDecodeFailed = &decodeError{"decode always fails"}
+
+type decodeError struct{ msg string }
+
+func (e *decodeError) Error() string { return e.msg }
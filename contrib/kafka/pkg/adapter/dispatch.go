@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/knative/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultBurst          = 1
+	defaultConcurrency    = 1
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// dispatchJob is a single message handed off to a dispatch worker, along
+// with the session used to mark it once successfully sent, the claim it
+// was read from (so the consumer lag gauge can be recorded against the
+// high water mark at the time the message is actually marked, not when it
+// was polled), and the context (threaded from Start) whose cancellation
+// should interrupt dispatch.
+type dispatchJob struct {
+	ctx   context.Context
+	sess  sarama.ConsumerGroupSession
+	claim sarama.ConsumerGroupClaim
+	msg   *sarama.ConsumerMessage
+	done  func()
+}
+
+// startDispatchWorkers lazily starts the adapter's dispatch worker pool and
+// rate limiter. It is safe to call repeatedly; only the first call has any
+// effect.
+func (a *Adapter) startDispatchWorkers() {
+	a.workersOnce.Do(func() {
+		if a.EventsPerSecond > 0 {
+			burst := a.Burst
+			if burst <= 0 {
+				burst = defaultBurst
+			}
+			a.limiter = rate.NewLimiter(rate.Limit(a.EventsPerSecond), burst)
+		}
+
+		concurrency := a.Concurrency
+		if concurrency <= 0 {
+			concurrency = defaultConcurrency
+		}
+		a.workerQueue = make([]chan dispatchJob, concurrency)
+		for i := range a.workerQueue {
+			queue := make(chan dispatchJob, concurrency)
+			a.workerQueue[i] = queue
+			go a.runDispatchWorker(queue)
+		}
+	})
+}
+
+// dispatchQueueFor returns the worker queue responsible for partition.
+// Keying on the partition number (rather than round-robining) guarantees
+// every message for a given partition is handled by the same worker, so
+// in-partition ordering and offset marking stay correct.
+func (a *Adapter) dispatchQueueFor(partition int32) chan<- dispatchJob {
+	return a.workerQueue[int(partition)%len(a.workerQueue)]
+}
+
+func (a *Adapter) runDispatchWorker(queue <-chan dispatchJob) {
+	for job := range queue {
+		a.dispatchWithRetry(job.ctx, job.sess, job.claim, job.msg)
+		job.done()
+	}
+}
+
+// dispatchWithRetry rate-limits and sends msg, retrying with exponential
+// backoff on failure. The offset is only marked once the sink accepts the
+// event; a message that exhausts its retries is logged and left unmarked
+// rather than silently skipped. ctx is honored throughout: a canceled ctx
+// aborts an in-flight rate-limit wait or retry backoff immediately, so a
+// graceful shutdown isn't blocked behind a stuck message.
+func (a *Adapter) dispatchWithRetry(ctx context.Context, sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim, msg *sarama.ConsumerMessage) {
+	logger := logging.FromContext(ctx)
+
+	if a.limiter != nil {
+		if err := a.limiter.Wait(ctx); err != nil {
+			logger.Error("Rate limiter wait failed: ", zap.Error(err))
+			return
+		}
+	}
+
+	backoff := a.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	maxBackoff := a.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := a.postMessage(ctx, msg)
+		latencyMs := float64(time.Since(start)) / float64(time.Millisecond)
+		recordDispatchResult(ctx, msg.Topic, msg.Partition, err == nil, latencyMs)
+
+		if err == nil {
+			sess.MarkMessage(msg, "")
+			recordConsumerLag(ctx, msg.Topic, msg.Partition, claim.HighWaterMarkOffset()-msg.Offset)
+			logger.Debug("Successfully sent event to sink")
+			return
+		}
+
+		if attempt >= a.MaxRetries {
+			logger.Error("Sending event to sink failed, giving up after retries: ", zap.Error(err))
+			return
+		}
+
+		logger.Error("Sending event to sink failed, retrying: ", zap.Error(err))
+		select {
+		case <-ctx.Done():
+			logger.Info("Context canceled, abandoning retry: ", zap.Error(ctx.Err()))
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
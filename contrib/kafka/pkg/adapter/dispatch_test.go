@@ -0,0 +1,58 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import "testing"
+
+func TestDispatchQueueFor(t *testing.T) {
+	a := &Adapter{workerQueue: make([]chan dispatchJob, 4)}
+	for i := range a.workerQueue {
+		a.workerQueue[i] = make(chan dispatchJob, 1)
+	}
+
+	tests := []struct {
+		partition int32
+		want      int
+	}{
+		{partition: 0, want: 0},
+		{partition: 1, want: 1},
+		{partition: 4, want: 0},
+		{partition: 5, want: 1},
+		{partition: 103, want: 3},
+	}
+	for _, tt := range tests {
+		got := a.dispatchQueueFor(tt.partition)
+		if got != a.workerQueue[tt.want] {
+			t.Errorf("dispatchQueueFor(%d) did not return workerQueue[%d]", tt.partition, tt.want)
+		}
+	}
+}
+
+func TestDispatchQueueFor_SamePartitionSameWorker(t *testing.T) {
+	a := &Adapter{workerQueue: make([]chan dispatchJob, 3)}
+	for i := range a.workerQueue {
+		a.workerQueue[i] = make(chan dispatchJob, 1)
+	}
+
+	for partition := int32(0); partition < 20; partition++ {
+		first := a.dispatchQueueFor(partition)
+		second := a.dispatchQueueFor(partition)
+		if first != second {
+			t.Errorf("dispatchQueueFor(%d) returned different queues on repeated calls, want routing to stay stable for ordering", partition)
+		}
+	}
+}
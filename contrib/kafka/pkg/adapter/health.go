@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultHealthAddr = ":8080"
+
+// healthState tracks the state surfaced by /healthz and /readyz: whether
+// the adapter currently holds consumer group membership, and when it last
+// successfully polled a message.
+type healthState struct {
+	mu       sync.RWMutex
+	healthy  bool
+	lastPoll time.Time
+}
+
+func (a *Adapter) setHealthy(healthy bool) {
+	a.health.mu.Lock()
+	a.health.healthy = healthy
+	a.health.mu.Unlock()
+}
+
+func (a *Adapter) recordPoll() {
+	a.health.mu.Lock()
+	a.health.lastPoll = time.Now()
+	a.health.mu.Unlock()
+}
+
+func (a *Adapter) snapshotHealth() (healthy bool, lastPoll time.Time) {
+	a.health.mu.RLock()
+	defer a.health.mu.RUnlock()
+	return a.health.healthy, a.health.lastPoll
+}
+
+// startHealthServer serves /healthz (always OK once the process is up) and
+// /readyz (OK only while the adapter holds consumer group membership) on
+// a.HealthAddr, so Kubernetes liveness/readiness probes have something to
+// check.
+func (a *Adapter) startHealthServer(logger *zap.SugaredLogger) {
+	addr := a.HealthAddr
+	if addr == "" {
+		addr = defaultHealthAddr
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/readyz", a.handleReadyz)
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("Health endpoint server exited: ", zap.Error(err))
+		}
+	}()
+}
+
+func (a *Adapter) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (a *Adapter) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	healthy, lastPoll := a.snapshotHealth()
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("not ready: consumer group not joined\n"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "ready: last poll at %s\n", lastPoll.Format(time.RFC3339))
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
+	"golang.org/x/net/context"
+)
+
+const (
+	// HeaderExtensionPrefixDefault is prepended to each Kafka record
+	// header name when it is copied onto the CloudEvent as an extension
+	// attribute, unless Adapter.HeaderExtensionPrefix overrides it.
+	HeaderExtensionPrefixDefault = "kafkaheader"
+
+	// KeyTypeString treats msg.Key as a raw UTF-8 string (the default).
+	KeyTypeString = "string"
+	// KeyTypeInt decodes msg.Key as a big-endian 32- or 64-bit integer.
+	KeyTypeInt = "int"
+	// KeyTypeFloat decodes msg.Key as a big-endian 32- or 64-bit float.
+	KeyTypeFloat = "float"
+	// KeyTypeByteArray base64-encodes msg.Key verbatim.
+	KeyTypeByteArray = "byte-array"
+
+	ceHeaderPrefix        = "ce_"
+	ceContentTypeHeader   = "content-type"
+	structuredContentType = "application/cloudevents+json"
+)
+
+// headerExtensions maps every Kafka record header onto a CloudEvents
+// extension attribute, sanitizing names to satisfy the CE spec (lower-case
+// alphanumeric) and prefixing them so they can't collide with first-class
+// attributes.
+func (a *Adapter) headerExtensions(headers []*sarama.RecordHeader) map[string]interface{} {
+	prefix := a.HeaderExtensionPrefix
+	if prefix == "" {
+		prefix = HeaderExtensionPrefixDefault
+	}
+
+	extensions := make(map[string]interface{}, len(headers))
+	for _, h := range headers {
+		name := sanitizeExtensionName(prefix + string(h.Key))
+		if name == "" {
+			continue
+		}
+		extensions[name] = string(h.Value)
+	}
+	return extensions
+}
+
+// sanitizeExtensionName lower-cases name and strips any character outside
+// [a-z0-9], matching the CloudEvents spec's constraint on extension
+// attribute names.
+func sanitizeExtensionName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(r - 'A' + 'a')
+		}
+	}
+	return b.String()
+}
+
+// encodeKey decodes msg.Key according to a.KeyType, defaulting to treating
+// it as a plain string.
+func (a *Adapter) encodeKey(key []byte) interface{} {
+	switch a.KeyType {
+	case KeyTypeInt:
+		switch len(key) {
+		case 4:
+			return int64(int32(binary.BigEndian.Uint32(key)))
+		case 8:
+			return int64(binary.BigEndian.Uint64(key))
+		default:
+			return string(key)
+		}
+	case KeyTypeFloat:
+		switch len(key) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(key)))
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(key))
+		default:
+			return string(key)
+		}
+	case KeyTypeByteArray:
+		return base64.StdEncoding.EncodeToString(key)
+	default:
+		return string(key)
+	}
+}
+
+// headerValue returns the value of the first header named key, or "" if
+// absent.
+func headerValue(headers []*sarama.RecordHeader, key string) string {
+	for _, h := range headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// parseEmbeddedCloudEvent detects a Kafka message that already carries a
+// CloudEvent, in either structured mode (a content-type of
+// application/cloudevents+json, with the full event JSON-encoded as the
+// value) or binary mode (ce_* headers alongside a raw payload), and parses
+// it directly rather than synthesizing a new event around the raw value.
+// The second return value is false if msg does not look like a CloudEvent.
+func (a *Adapter) parseEmbeddedCloudEvent(ctx context.Context, msg *sarama.ConsumerMessage) (*cloudevents.Event, bool) {
+	if headerValue(msg.Headers, ceContentTypeHeader) == structuredContentType {
+		event, err := parseStructuredCloudEvent(msg.Value)
+		if err != nil {
+			return nil, false
+		}
+		return event, true
+	}
+
+	if headerValue(msg.Headers, ceHeaderPrefix+"specversion") == "" {
+		return nil, false
+	}
+	return a.parseBinaryCloudEvent(ctx, msg), true
+}
+
+// parseStructuredCloudEvent parses a structured-mode CloudEvent, as
+// produced by a CE-aware Kafka client, from its JSON envelope.
+func parseStructuredCloudEvent(data []byte) (*cloudevents.Event, error) {
+	var ectx cloudevents.EventContextV02
+	if err := json.Unmarshal(data, &ectx); err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	var payload interface{}
+	if len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, &payload); err != nil {
+			payload = []byte(envelope.Data)
+		}
+	}
+
+	event := cloudevents.Event{Context: ectx.AsV02(), Data: payload}
+	return &event, nil
+}
+
+// parseBinaryCloudEvent reconstructs a binary-mode CloudEvent from its
+// ce_-prefixed Kafka headers and raw value.
+func (a *Adapter) parseBinaryCloudEvent(ctx context.Context, msg *sarama.ConsumerMessage) *cloudevents.Event {
+	ectx := cloudevents.EventContextV02{SpecVersion: cloudevents.CloudEventsVersionV02}
+	extensions := map[string]interface{}{}
+
+	for _, h := range msg.Headers {
+		key := string(h.Key)
+		value := string(h.Value)
+		switch key {
+		case ceHeaderPrefix + "specversion":
+			ectx.SpecVersion = value
+		case ceHeaderPrefix + "type":
+			ectx.Type = value
+		case ceHeaderPrefix + "source":
+			ectx.Source = *types.ParseURLRef(value)
+		case ceHeaderPrefix + "id":
+			ectx.ID = value
+		case ceHeaderPrefix + "time":
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				ectx.Time = &types.Timestamp{Time: t}
+			}
+		case ceContentTypeHeader:
+			ct := value
+			ectx.ContentType = &ct
+		default:
+			if strings.HasPrefix(key, ceHeaderPrefix) {
+				name := sanitizeExtensionName(strings.TrimPrefix(key, ceHeaderPrefix))
+				extensions[name] = value
+			}
+		}
+	}
+	if len(extensions) > 0 {
+		ectx.Extensions = extensions
+	}
+
+	data, contentType := a.decodePayload(ctx, msg.Value)
+	if ectx.ContentType == nil {
+		ectx.ContentType = &contentType
+	}
+
+	return &cloudevents.Event{
+		Context: ectx.AsV02(),
+		Data:    data,
+	}
+}
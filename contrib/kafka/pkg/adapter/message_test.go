@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/net/context"
+)
+
+func TestSanitizeExtensionName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "already valid", in: "kafkaheadertraceid", want: "kafkaheadertraceid"},
+		{name: "upper-cased", in: "Kafkaheader-Trace-ID", want: "kafkaheadertraceid"},
+		{name: "strips hyphens and underscores", in: "kafkaheader_trace-id", want: "kafkaheadertraceid"},
+		{name: "strips non-ascii and punctuation", in: "kafkaheader.trace!éid", want: "kafkaheadertraceid"},
+		{name: "empty", in: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeExtensionName(tt.in); got != tt.want {
+				t.Errorf("sanitizeExtensionName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyType string
+		key     []byte
+		want    interface{}
+	}{
+		{name: "default is string", keyType: "", key: []byte("abc"), want: "abc"},
+		{name: "explicit string", keyType: KeyTypeString, key: []byte("abc"), want: "abc"},
+		{name: "int32", keyType: KeyTypeInt, key: []byte{0x00, 0x00, 0x00, 0x2a}, want: int64(42)},
+		{name: "int64", keyType: KeyTypeInt, key: []byte{0, 0, 0, 0, 0, 0, 0, 42}, want: int64(42)},
+		{name: "int falls back to string on bad length", keyType: KeyTypeInt, key: []byte("abc"), want: "abc"},
+		{name: "float32", keyType: KeyTypeFloat, key: []byte{0x42, 0x28, 0x00, 0x00}, want: float64(42)},
+		{name: "byte-array", keyType: KeyTypeByteArray, key: []byte{1, 2, 3}, want: base64.StdEncoding.EncodeToString([]byte{1, 2, 3})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Adapter{KeyType: tt.keyType}
+			got := a.encodeKey(tt.key)
+			if got != tt.want {
+				t.Errorf("encodeKey(%v) with KeyType=%q = %v (%T), want %v (%T)", tt.key, tt.keyType, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderExtensions(t *testing.T) {
+	a := &Adapter{}
+	headers := []*sarama.RecordHeader{
+		{Key: []byte("trace-id"), Value: []byte("abc123")},
+	}
+
+	got := a.headerExtensions(headers)
+	want := "abc123"
+	if got["kafkaheadertraceid"] != want {
+		t.Errorf("headerExtensions()[kafkaheadertraceid] = %v, want %v", got["kafkaheadertraceid"], want)
+	}
+
+	a.HeaderExtensionPrefix = "custom"
+	got = a.headerExtensions(headers)
+	if got["customtraceid"] != want {
+		t.Errorf("headerExtensions() with custom prefix = %v, want key customtraceid = %v", got, want)
+	}
+}
+
+func TestParseEmbeddedCloudEvent_NotEmbedded(t *testing.T) {
+	a := &Adapter{payloadDecoder: jsonDecoder{}}
+	msg := &sarama.ConsumerMessage{Value: []byte(`{"hello":"world"}`)}
+
+	if _, ok := a.parseEmbeddedCloudEvent(context.TODO(), msg); ok {
+		t.Errorf("parseEmbeddedCloudEvent() = ok, want a plain JSON message to not be detected as an embedded CloudEvent")
+	}
+}
+
+func TestParseEmbeddedCloudEvent_Binary(t *testing.T) {
+	a := &Adapter{payloadDecoder: jsonDecoder{}}
+	msg := &sarama.ConsumerMessage{
+		Topic: "my-topic",
+		Value: []byte(`{"hello":"world"}`),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("ce_specversion"), Value: []byte("0.2")},
+			{Key: []byte("ce_type"), Value: []byte("com.example.test")},
+			{Key: []byte("ce_source"), Value: []byte("/my/source")},
+			{Key: []byte("ce_id"), Value: []byte("abc-123")},
+		},
+	}
+
+	event, ok := a.parseEmbeddedCloudEvent(context.TODO(), msg)
+	if !ok {
+		t.Fatalf("parseEmbeddedCloudEvent() = not ok, want the ce_* headers to be detected as an embedded binary CloudEvent")
+	}
+	if event.Type() != "com.example.test" {
+		t.Errorf("event.Type() = %q, want %q", event.Type(), "com.example.test")
+	}
+	if event.ID() != "abc-123" {
+		t.Errorf("event.ID() = %q, want %q", event.ID(), "abc-123")
+	}
+}
+
+func TestParseEmbeddedCloudEvent_Structured(t *testing.T) {
+	a := &Adapter{}
+	body := `{"specversion":"0.2","type":"com.example.test","source":"/my/source","id":"abc-123","data":{"hello":"world"}}`
+	msg := &sarama.ConsumerMessage{
+		Value: []byte(body),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("content-type"), Value: []byte("application/cloudevents+json")},
+		},
+	}
+
+	event, ok := a.parseEmbeddedCloudEvent(context.TODO(), msg)
+	if !ok {
+		t.Fatalf("parseEmbeddedCloudEvent() = not ok, want a structured-mode CloudEvent envelope to be detected")
+	}
+	if event.Type() != "com.example.test" {
+		t.Errorf("event.Type() = %q, want %q", event.Type(), "com.example.test")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"net/http"
+	"strconv"
+
+	prometheus "contrib.go.opencensus.io/exporter/prometheus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const defaultMetricsAddr = ":9090"
+
+var (
+	keyTopic     = tag.MustNewKey("topic")
+	keyPartition = tag.MustNewKey("partition")
+
+	mEventsConsumed = stats.Int64(
+		"kafka_source_events_consumed_total",
+		"Number of Kafka records consumed",
+		stats.UnitDimensionless)
+	mDispatchSuccess = stats.Int64(
+		"kafka_source_dispatch_success_total",
+		"Number of events successfully dispatched to the sink",
+		stats.UnitDimensionless)
+	mDispatchFailure = stats.Int64(
+		"kafka_source_dispatch_failure_total",
+		"Number of events that failed dispatch to the sink",
+		stats.UnitDimensionless)
+	mDispatchLatency = stats.Float64(
+		"kafka_source_dispatch_latency",
+		"Latency of sink dispatch calls, in milliseconds",
+		stats.UnitMilliseconds)
+	mConsumerLag = stats.Int64(
+		"kafka_source_consumer_lag",
+		"Difference between a partition's high water mark and the last marked (committed) offset",
+		stats.UnitDimensionless)
+)
+
+func init() {
+	err := view.Register(
+		&view.View{Measure: mEventsConsumed, Aggregation: view.Count(), TagKeys: []tag.Key{keyTopic, keyPartition}},
+		&view.View{Measure: mDispatchSuccess, Aggregation: view.Count(), TagKeys: []tag.Key{keyTopic, keyPartition}},
+		&view.View{Measure: mDispatchFailure, Aggregation: view.Count(), TagKeys: []tag.Key{keyTopic, keyPartition}},
+		&view.View{
+			Measure:     mDispatchLatency,
+			Aggregation: view.Distribution(1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+			TagKeys:     []tag.Key{keyTopic, keyPartition},
+		},
+		&view.View{Measure: mConsumerLag, Aggregation: view.LastValue(), TagKeys: []tag.Key{keyTopic, keyPartition}},
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// recordEventConsumed records that a record was read off topic/partition.
+func recordEventConsumed(ctx context.Context, topic string, partition int32) {
+	withTags(ctx, topic, partition, func(ctx context.Context) {
+		stats.Record(ctx, mEventsConsumed.M(1))
+	})
+}
+
+// recordDispatchResult records the outcome and latency of a single
+// dispatch attempt to the sink.
+func recordDispatchResult(ctx context.Context, topic string, partition int32, success bool, latencyMs float64) {
+	withTags(ctx, topic, partition, func(ctx context.Context) {
+		if success {
+			stats.Record(ctx, mDispatchSuccess.M(1), mDispatchLatency.M(latencyMs))
+		} else {
+			stats.Record(ctx, mDispatchFailure.M(1), mDispatchLatency.M(latencyMs))
+		}
+	})
+}
+
+// recordConsumerLag records the gap between a partition's high water mark
+// and the offset the adapter has actually marked (committed), so a sink
+// that's slow or stuck retrying shows up as real lag rather than being
+// masked by offsets that were merely polled.
+func recordConsumerLag(ctx context.Context, topic string, partition int32, lag int64) {
+	withTags(ctx, topic, partition, func(ctx context.Context) {
+		stats.Record(ctx, mConsumerLag.M(lag))
+	})
+}
+
+func withTags(ctx context.Context, topic string, partition int32, record func(context.Context)) {
+	tagged, err := tag.New(ctx,
+		tag.Insert(keyTopic, topic),
+		tag.Insert(keyPartition, strconv.Itoa(int(partition))))
+	if err != nil {
+		return
+	}
+	record(tagged)
+}
+
+// startMetricsServer serves Prometheus-formatted OpenCensus metrics on
+// a.MetricsAddr, alongside the health endpoints.
+func (a *Adapter) startMetricsServer(logger *zap.SugaredLogger) {
+	addr := a.MetricsAddr
+	if addr == "" {
+		addr = defaultMetricsAddr
+	}
+
+	exporter, err := prometheus.NewExporter(prometheus.Options{})
+	if err != nil {
+		logger.Error("Creating Prometheus exporter failed: ", zap.Error(err))
+		return
+	}
+	view.RegisterExporter(exporter)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("Metrics endpoint server exited: ", zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// buildTestDescriptorSet builds, in-process, the serialized bytes of a
+// FileDescriptorSet containing a single message type testpb.Greeting with
+// one string field "message" -- equivalent to what `protoc
+// --descriptor_set_out` would produce for:
+//
+//	syntax = "proto3";
+//	package testpb;
+//	message Greeting { string message = 1; }
+func buildTestDescriptorSet(t *testing.T) []byte {
+	t.Helper()
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("testpb"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Greeting"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("message"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						JsonName: proto.String("message"),
+					},
+				},
+			},
+		},
+	}
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fd}})
+	if err != nil {
+		t.Fatalf("marshaling test descriptor set: %v", err)
+	}
+	return data
+}
+
+func TestProtobufDecoder_Decode(t *testing.T) {
+	decoder, err := newProtobufDecoder(buildTestDescriptorSet(t), "testpb.Greeting")
+	if err != nil {
+		t.Fatalf("newProtobufDecoder() failed: %v", err)
+	}
+
+	msg := decoder.msgType.New()
+	msg.Set(msg.Descriptor().Fields().ByName("message"), protoreflect.ValueOfString("hello"))
+	raw, err := proto.Marshal(msg.Interface())
+	if err != nil {
+		t.Fatalf("marshaling test message: %v", err)
+	}
+
+	data, contentType, err := decoder.Decode(context.TODO(), raw)
+	if err != nil {
+		t.Fatalf("Decode() returned error: %v", err)
+	}
+	if contentType != "application/protobuf+json" {
+		t.Errorf("Decode() contentType = %q, want %q", contentType, "application/protobuf+json")
+	}
+	payload, ok := data.(map[string]interface{})
+	if !ok || payload["message"] != "hello" {
+		t.Errorf("Decode() data = %#v, want map with message=hello", data)
+	}
+}
+
+func TestNewProtobufDecoder_UnknownMessageType(t *testing.T) {
+	if _, err := newProtobufDecoder(buildTestDescriptorSet(t), "testpb.DoesNotExist"); err == nil {
+		t.Error("newProtobufDecoder() with an unknown message type returned nil error, want it to fail")
+	}
+}
+
+func TestNewProtobufDecoder_MalformedDescriptorSet(t *testing.T) {
+	if _, err := newProtobufDecoder([]byte("not a descriptor set"), "testpb.Greeting"); err == nil {
+		t.Error("newProtobufDecoder() with a malformed descriptor set returned nil error, want it to fail")
+	}
+}
+
+func TestProtobufDecoder_Decode_MalformedPayload(t *testing.T) {
+	decoder, err := newProtobufDecoder(buildTestDescriptorSet(t), "testpb.Greeting")
+	if err != nil {
+		t.Fatalf("newProtobufDecoder() failed: %v", err)
+	}
+
+	if _, _, err := decoder.Decode(context.TODO(), []byte{0xff, 0xff, 0xff}); err == nil {
+		t.Error("Decode() with a malformed payload returned nil error, want an error")
+	}
+}
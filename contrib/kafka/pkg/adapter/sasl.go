@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+const (
+	// SASLMechanismPlain is the default SASL mechanism, a plain user/password
+	// exchange sent over the wire (ideally under TLS).
+	SASLMechanismPlain = "PLAIN"
+	// SASLMechanismSCRAMSHA256 authenticates using SCRAM-SHA-256.
+	SASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	// SASLMechanismSCRAMSHA512 authenticates using SCRAM-SHA-512.
+	SASLMechanismSCRAMSHA512 = "SCRAM-SHA-512"
+	// SASLMechanismOAuthBearer authenticates by presenting a bearer token
+	// obtained out-of-band (e.g. from an OAuth2 token endpoint).
+	SASLMechanismOAuthBearer = "OAUTHBEARER"
+)
+
+// configureSASL wires the SASL settings of a.Net.SASL into the sarama config,
+// selecting the appropriate mechanism, SCRAM client generator, or token
+// provider.
+func (a *Adapter) configureSASL(kafkaConfig *sarama.Config) error {
+	sasl := a.Net.SASL
+	kafkaConfig.Net.SASL.Enable = sasl.Enable
+	if !sasl.Enable {
+		return nil
+	}
+
+	mechanism := sasl.Mechanism
+	if mechanism == "" {
+		mechanism = SASLMechanismPlain
+	}
+	kafkaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(mechanism)
+	kafkaConfig.Net.SASL.User = sasl.User
+	kafkaConfig.Net.SASL.Password = sasl.Password
+
+	switch mechanism {
+	case SASLMechanismPlain:
+		// Nothing further to configure; sarama handles PLAIN natively.
+	case SASLMechanismSCRAMSHA256:
+		kafkaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &xdgSCRAMClient{HashGeneratorFcn: sha256.New}
+		}
+	case SASLMechanismSCRAMSHA512:
+		kafkaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &xdgSCRAMClient{HashGeneratorFcn: sha512.New}
+		}
+	case SASLMechanismOAuthBearer:
+		kafkaConfig.Net.SASL.TokenProvider = &adapterTokenProvider{oauth: sasl.OAuth}
+	default:
+		return fmt.Errorf("kafka: unsupported SASL mechanism %q", mechanism)
+	}
+
+	return nil
+}
+
+// xdgSCRAMClient adapts github.com/xdg-go/scram to sarama.SCRAMClient.
+type xdgSCRAMClient struct {
+	*scram.Client
+	*scram.ClientConversation
+	HashGeneratorFcn scram.HashGeneratorFcn
+}
+
+func (c *xdgSCRAMClient) Begin(userName, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.Client = client
+	c.ClientConversation = c.Client.NewConversation()
+	return nil
+}
+
+func (c *xdgSCRAMClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+func (c *xdgSCRAMClient) Done() bool {
+	return c.ClientConversation.Done()
+}
+
+// adapterTokenProvider implements sarama.AccessTokenProvider for the
+// OAUTHBEARER mechanism. When a StaticToken is configured it is returned
+// as-is; otherwise a token is fetched from TokenEndpoint using the client
+// credentials grant.
+type adapterTokenProvider struct {
+	oauth AdapterOAuth
+}
+
+func (p *adapterTokenProvider) Token() (*sarama.AccessToken, error) {
+	if p.oauth.StaticToken != "" {
+		return &sarama.AccessToken{Token: p.oauth.StaticToken}, nil
+	}
+	if p.oauth.TokenEndpoint == "" {
+		return nil, fmt.Errorf("kafka: OAUTHBEARER configured without a StaticToken or TokenEndpoint")
+	}
+	token, err := fetchClientCredentialsToken(p.oauth)
+	if err != nil {
+		return nil, err
+	}
+	return &sarama.AccessToken{Token: token}, nil
+}
+
+// oauthTokenRequestTimeout bounds how long a single OAuth token fetch can
+// take. Token() is called synchronously on every broker (re)connect,
+// including from runSupervised's reconnect loop, so a hung token
+// endpoint must not be allowed to hang the reconnect attempt indefinitely.
+const oauthTokenRequestTimeout = 10 * time.Second
+
+var oauthTokenHTTPClient = &http.Client{Timeout: oauthTokenRequestTimeout}
+
+// fetchClientCredentialsToken exchanges the configured client ID/secret for
+// a bearer token using the OAuth2 client credentials grant.
+func fetchClientCredentialsToken(oauth AdapterOAuth) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", oauth.ClientID)
+	form.Set("client_secret", oauth.ClientSecret)
+	if len(oauth.Scopes) > 0 {
+		form.Set("scope", strings.Join(oauth.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, oauth.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("kafka: building OAuth token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := oauthTokenHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("kafka: requesting OAuth token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("kafka: OAuth token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("kafka: decoding OAuth token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("kafka: OAuth token endpoint response did not contain an access_token")
+	}
+	return body.AccessToken, nil
+}
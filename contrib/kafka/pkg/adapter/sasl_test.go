@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestConfigureSASL_Disabled(t *testing.T) {
+	a := &Adapter{}
+	cfg := &sarama.Config{}
+	if err := a.configureSASL(cfg); err != nil {
+		t.Fatalf("configureSASL() returned error: %v", err)
+	}
+	if cfg.Net.SASL.Enable {
+		t.Error("configureSASL() enabled SASL when Net.SASL.Enable was false")
+	}
+}
+
+func TestConfigureSASL_Plain(t *testing.T) {
+	a := &Adapter{Net: AdapterNet{SASL: AdapterSASL{Enable: true, User: "u", Password: "p"}}}
+	cfg := &sarama.Config{}
+	if err := a.configureSASL(cfg); err != nil {
+		t.Fatalf("configureSASL() returned error: %v", err)
+	}
+	if cfg.Net.SASL.Mechanism != sarama.SASLMechanism(SASLMechanismPlain) {
+		t.Errorf("configureSASL() mechanism = %v, want %v", cfg.Net.SASL.Mechanism, SASLMechanismPlain)
+	}
+	if cfg.Net.SASL.User != "u" || cfg.Net.SASL.Password != "p" {
+		t.Errorf("configureSASL() did not propagate user/password: %+v", cfg.Net.SASL)
+	}
+}
+
+func TestConfigureSASL_SCRAM(t *testing.T) {
+	tests := []struct {
+		name      string
+		mechanism string
+	}{
+		{name: "sha256", mechanism: SASLMechanismSCRAMSHA256},
+		{name: "sha512", mechanism: SASLMechanismSCRAMSHA512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Adapter{Net: AdapterNet{SASL: AdapterSASL{Enable: true, Mechanism: tt.mechanism}}}
+			cfg := &sarama.Config{}
+			if err := a.configureSASL(cfg); err != nil {
+				t.Fatalf("configureSASL() returned error: %v", err)
+			}
+			if cfg.Net.SASL.SCRAMClientGeneratorFunc == nil {
+				t.Fatal("configureSASL() did not set SCRAMClientGeneratorFunc")
+			}
+			client := cfg.Net.SASL.SCRAMClientGeneratorFunc()
+			if _, ok := client.(*xdgSCRAMClient); !ok {
+				t.Errorf("configureSASL() SCRAMClientGeneratorFunc() = %T, want *xdgSCRAMClient", client)
+			}
+		})
+	}
+}
+
+func TestConfigureSASL_OAuthBearer(t *testing.T) {
+	a := &Adapter{Net: AdapterNet{SASL: AdapterSASL{
+		Enable:    true,
+		Mechanism: SASLMechanismOAuthBearer,
+		OAuth:     AdapterOAuth{StaticToken: "tok"},
+	}}}
+	cfg := &sarama.Config{}
+	if err := a.configureSASL(cfg); err != nil {
+		t.Fatalf("configureSASL() returned error: %v", err)
+	}
+	provider, ok := cfg.Net.SASL.TokenProvider.(*adapterTokenProvider)
+	if !ok {
+		t.Fatalf("configureSASL() TokenProvider = %T, want *adapterTokenProvider", cfg.Net.SASL.TokenProvider)
+	}
+	token, err := provider.Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %v", err)
+	}
+	if token.Token != "tok" {
+		t.Errorf("Token() = %q, want %q", token.Token, "tok")
+	}
+}
+
+func TestConfigureSASL_OAuthBearer_MissingEndpoint(t *testing.T) {
+	provider := &adapterTokenProvider{oauth: AdapterOAuth{}}
+	if _, err := provider.Token(); err == nil {
+		t.Error("Token() with neither StaticToken nor TokenEndpoint set returned nil error, want an error")
+	}
+}
+
+func TestConfigureSASL_UnsupportedMechanism(t *testing.T) {
+	a := &Adapter{Net: AdapterNet{SASL: AdapterSASL{Enable: true, Mechanism: "UNKNOWN"}}}
+	cfg := &sarama.Config{}
+	if err := a.configureSASL(cfg); err == nil {
+		t.Error("configureSASL() with an unsupported mechanism returned nil error, want an error")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const (
+	supervisorInitialBackoff       = 1 * time.Second
+	supervisorMaxBackoff           = 30 * time.Second
+	defaultMetadataRefreshInterval = 10 * time.Minute
+)
+
+// runSupervised runs successive consumer group sessions, restarting with
+// exponential backoff whenever one exits with an error, until ctx is
+// canceled.
+func (a *Adapter) runSupervised(ctx context.Context, logger *zap.SugaredLogger) error {
+	backoff := supervisorInitialBackoff
+	for ctx.Err() == nil {
+		err := a.runConsumerGroup(ctx, logger)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+
+		a.setHealthy(false)
+		logger.Error("Consumer group session failed, restarting: ", zap.Error(err), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > supervisorMaxBackoff {
+			backoff = supervisorMaxBackoff
+		}
+	}
+	return nil
+}
+
+// runConsumerGroup creates a sarama client and consumer group and consumes
+// from it until ctx is canceled or an unrecoverable error occurs. It also
+// runs the periodic metadata-refresh ticker used to surface rebalance
+// storms.
+func (a *Adapter) runConsumerGroup(ctx context.Context, logger *zap.SugaredLogger) error {
+	kafkaConfig, err := a.newKafkaConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := sarama.NewClient(strings.Split(a.BootstrapServers, ","), kafkaConfig)
+	if err != nil {
+		return fmt.Errorf("kafka: creating client: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if a.ResetOffsets && !a.offsetsReset {
+		if err := a.resetConsumerGroupOffsets(client, kafkaConfig); err != nil {
+			return fmt.Errorf("kafka: resetting consumer group offsets: %w", err)
+		}
+		a.offsetsReset = true
+	}
+
+	group, err := sarama.NewConsumerGroupFromClient(a.ConsumerGroup, client)
+	if err != nil {
+		return fmt.Errorf("kafka: creating consumer group: %w", err)
+	}
+	defer func() { _ = group.Close() }()
+
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		for err := range group.Errors() {
+			logger.Error("Consumer group error: ", zap.Error(err))
+		}
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		topics := strings.Split(a.Topics, ",")
+		for groupCtx.Err() == nil {
+			if err := group.Consume(groupCtx, topics, a); err != nil {
+				errCh <- err
+				cancel()
+				return
+			}
+		}
+	}()
+
+	refreshInterval := a.MetadataRefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultMetadataRefreshInterval
+	}
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	leaders := map[string]map[int32]int32{}
+	for {
+		select {
+		case <-groupCtx.Done():
+			select {
+			case err := <-errCh:
+				return err
+			default:
+				return nil
+			}
+		case <-ticker.C:
+			a.logMetadataChanges(client, logger, leaders)
+		}
+	}
+}
+
+// logMetadataChanges refreshes client's cached metadata and logs any
+// partition leadership change since the last refresh, so operators can
+// observe rebalance storms.
+func (a *Adapter) logMetadataChanges(client sarama.Client, logger *zap.SugaredLogger, leaders map[string]map[int32]int32) {
+	if err := client.RefreshMetadata(); err != nil {
+		logger.Error("Metadata refresh failed: ", zap.Error(err))
+		return
+	}
+
+	for _, topic := range strings.Split(a.Topics, ",") {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			logger.Error("Listing partitions failed: ", zap.Error(err), zap.String("topic", topic))
+			continue
+		}
+
+		topicLeaders := leaders[topic]
+		if topicLeaders == nil {
+			topicLeaders = make(map[int32]int32, len(partitions))
+			leaders[topic] = topicLeaders
+		}
+
+		for _, partition := range partitions {
+			broker, err := client.Leader(topic, partition)
+			if err != nil {
+				logger.Error("Finding partition leader failed: ", zap.Error(err), zap.String("topic", topic), zap.Int32("partition", partition))
+				continue
+			}
+			if prev, ok := topicLeaders[partition]; ok && prev != broker.ID() {
+				logger.Info("Partition leader changed",
+					zap.String("topic", topic),
+					zap.Int32("partition", partition),
+					zap.Int32("from", prev),
+					zap.Int32("to", broker.ID()))
+			}
+			topicLeaders[partition] = broker.ID()
+		}
+	}
+}
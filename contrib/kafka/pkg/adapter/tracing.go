@@ -0,0 +1,35 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kafka
+
+import (
+	"fmt"
+
+	"go.opencensus.io/trace"
+)
+
+// traceParentHeader formats sc as a W3C traceparent value
+// (https://www.w3.org/TR/trace-context/#traceparent-header), so that a
+// downstream sink receiving the CloudEvent as the "traceparent" extension
+// can continue the same trace.
+func traceParentHeader(sc trace.SpanContext) string {
+	flags := byte(0)
+	if sc.IsSampled() {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%032x-%016x-%02x", sc.TraceID[:], sc.SpanID[:], flags)
+}